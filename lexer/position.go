@@ -0,0 +1,168 @@
+package lexer
+
+import (
+  "fmt"
+  "sort"
+)
+
+// Pos is a compact source position: a byte offset into the address space
+// owned by a FileSet.  Comparing two Pos values from the same FileSet tells
+// you their relative order even across file boundaries, the same way
+// go/token.Pos does.
+type Pos int
+
+// NoPos is the zero value for Pos.  It carries no source information; callers
+// should check p == NoPos before resolving a position.
+const NoPos Pos = 0
+
+// IsValid reports whether the position refers to an actual source location.
+func (p Pos) IsValid() bool {
+  return p != NoPos
+}
+
+// Position is the resolved, human-readable form of a Pos: a filename along
+// with the 1-based line and column and the raw byte offset within that file.
+type Position struct {
+  Filename string
+  Offset   int
+  Line     int
+  Column   int
+}
+
+// IsValid reports whether the position has a known line, i.e. it was
+// resolved against a File rather than left as the zero value.
+func (pos *Position) IsValid() bool {
+  return pos.Line > 0
+}
+
+// String renders the position as "file:line:col", the form used when
+// reporting errors back to the user.
+func (pos Position) String() string {
+  s := pos.Filename
+  if s == "" {
+    s = "-"
+  }
+  if pos.IsValid() {
+    s += fmt.Sprintf(":%d:%d", pos.Line, pos.Column)
+  }
+  return s
+}
+
+// File tracks the line boundaries discovered while scanning a single source
+// file, so that any Pos within its range can be resolved back to a line and
+// column.  A File is created by FileSet.AddFile and is owned by that set for
+// the rest of its life.
+type File struct {
+  set   *FileSet
+  name  string
+  base  int
+  size  int
+  lines []int // byte offset of the first character of each line; lines[0] == 0
+}
+
+// Name returns the filename the File was registered under.
+func (f *File) Name() string {
+  return f.name
+}
+
+// Base returns the offset at which this file's Pos values begin.
+func (f *File) Base() int {
+  return f.base
+}
+
+// Size returns the size, in bytes, of the file's source text.
+func (f *File) Size() int {
+  return f.size
+}
+
+// AddLine records that a newline was scanned at the given byte offset, so
+// that the following line can be resolved to a Position later.  Offsets must
+// be added in strictly increasing order, as the scanner encounters them.
+func (f *File) AddLine(offset int) {
+  if n := len(f.lines); (n == 0 || f.lines[n-1] < offset) && offset < f.size {
+    f.lines = append(f.lines, offset)
+  }
+}
+
+// Pos returns the Pos corresponding to a byte offset within this file.
+func (f *File) Pos(offset int) Pos {
+  return Pos(f.base + offset)
+}
+
+// Offset returns the byte offset of p within this file.
+func (f *File) Offset(p Pos) int {
+  return int(p) - f.base
+}
+
+// Position resolves a Pos owned by this file into its filename, offset, line
+// and column.
+func (f *File) Position(p Pos) Position {
+  offset := f.Offset(p)
+  line, column := f.lineColumn(offset)
+  return Position{
+    Filename: f.name,
+    Offset:   offset,
+    Line:     line,
+    Column:   column,
+  }
+}
+
+// lineColumn finds the line containing offset via binary search over the
+// recorded line-start table, mirroring go/token.File.position.
+func (f *File) lineColumn(offset int) (line, column int) {
+  i := sort.Search(len(f.lines), func(i int) bool { return f.lines[i] > offset }) - 1
+  if i < 0 {
+    i = 0
+  }
+  return i + 1, offset - f.lines[i] + 1
+}
+
+// FileSet groups the files of a single scan into one shared Pos address
+// space, so that Pos values from different files never collide.  It mirrors
+// the role of go/token.FileSet.
+type FileSet struct {
+  base  int
+  files []*File
+}
+
+// NewFileSet creates an empty FileSet.  The base offset starts at 1 so that
+// NoPos (0) never aliases a real position.
+func NewFileSet() *FileSet {
+  return &FileSet{base: 1}
+}
+
+// AddFile registers a new file of the given name and size, returning a File
+// whose Pos values begin right after every file already registered with this
+// set.
+func (s *FileSet) AddFile(filename string, size int) *File {
+  f := &File{
+    set:   s,
+    name:  filename,
+    base:  s.base,
+    size:  size,
+    lines: []int{0},
+  }
+  s.base += size + 1 // +1 keeps files from sharing a boundary Pos
+  s.files = append(s.files, f)
+  return f
+}
+
+// File returns the File that owns p, or nil if no registered file contains
+// it.
+func (s *FileSet) File(p Pos) *File {
+  for _, f := range s.files {
+    if f.base <= int(p) && int(p) <= f.base+f.size {
+      return f
+    }
+  }
+  return nil
+}
+
+// Position resolves p by locating its owning File and delegating to
+// File.Position.  It returns the zero Position if no file owns p.
+func (s *FileSet) Position(p Pos) Position {
+  if f := s.File(p); f != nil {
+    return f.Position(p)
+  }
+  return Position{}
+}
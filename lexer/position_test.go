@@ -0,0 +1,73 @@
+package lexer
+
+import "testing"
+
+func TestFilePositionLineColumn(t *testing.T) {
+  fset := NewFileSet()
+  src := "ab\ncd\nef"
+  f := fset.AddFile("t", len(src))
+  for i := 0; i < len(src); i++ {
+    if src[i] == '\n' {
+      f.AddLine(i + 1)
+    }
+  }
+
+  cases := []struct {
+    offset       int
+    line, column int
+  }{
+    {0, 1, 1}, // 'a'
+    {1, 1, 2}, // 'b'
+    {3, 2, 1}, // 'c'
+    {4, 2, 2}, // 'd'
+    {6, 3, 1}, // 'e'
+    {7, 3, 2}, // 'f'
+  }
+  for _, c := range cases {
+    pos := f.Position(f.Pos(c.offset))
+    if pos.Line != c.line || pos.Column != c.column {
+      t.Errorf("offset %d: got line %d col %d, want line %d col %d",
+        c.offset, pos.Line, pos.Column, c.line, c.column)
+    }
+  }
+}
+
+func TestFileSetSeparatesFiles(t *testing.T) {
+  fset := NewFileSet()
+  a := fset.AddFile("a", 5)
+  b := fset.AddFile("b", 5)
+
+  pa := a.Pos(2)
+  pb := b.Pos(2)
+  if pa == pb {
+    t.Fatalf("positions from different files collided: %v == %v", pa, pb)
+  }
+  if fset.File(pa) != a {
+    t.Fatalf("FileSet.File(pa) did not resolve back to file a")
+  }
+  if fset.File(pb) != b {
+    t.Fatalf("FileSet.File(pb) did not resolve back to file b")
+  }
+}
+
+func TestPositionString(t *testing.T) {
+  fset := NewFileSet()
+  src := "line one\nline two"
+  f := fset.AddFile("example.ucfg", len(src))
+  for i := range src {
+    if src[i] == '\n' {
+      f.AddLine(i + 1)
+    }
+  }
+
+  pos := f.Position(f.Pos(9)) // 'l' of "line two"
+  if got, want := pos.String(), "example.ucfg:2:1"; got != want {
+    t.Fatalf("pos.String() = %q, want %q", got, want)
+  }
+}
+
+func TestNoPosIsInvalid(t *testing.T) {
+  if NoPos.IsValid() {
+    t.Fatalf("NoPos.IsValid() = true, want false")
+  }
+}
@@ -0,0 +1,661 @@
+package lexer
+
+import (
+  "strings"
+  "unicode"
+  "unicode/utf8"
+)
+
+// ErrorHandler is called by a Scanner for each lexical error encountered
+// while scanning, with the resolved Position of the offending byte and a
+// human-readable message.
+type ErrorHandler func(pos Position, msg string)
+
+const eof = -1
+
+// Mode is a bitmask of optional Scanner behaviors, passed to Init.
+type Mode uint
+
+const (
+  // ScanComments causes Scan to return COMMENT tokens instead of silently
+  // skipping over comments.
+  ScanComments Mode = 1 << iota
+)
+
+// Scanner turns source bytes into a stream of tokens, modeled closely on
+// go/scanner.Scanner but driving the Token enum declared in this package.
+// Callers drive it by repeatedly calling Scan until it returns EOF.
+type Scanner struct {
+  file *File
+  err  ErrorHandler
+  src  []byte
+  mode Mode
+
+  ch       rune // current character
+  offset   int  // offset of ch
+  rdOffset int  // offset of the character after ch
+
+  // insertSemi is set when the last token returned could end a statement,
+  // so a following newline should be turned into a synthetic SEMICOLON
+  // rather than skipped as whitespace.
+  insertSemi bool
+
+  // interpStack tracks interpolated-string expressions the scanner is
+  // currently nested inside.  Each entry counts the '(' tokens opened (and
+  // not yet closed) since that expression began, so the ')' that actually
+  // closes the interpolation - as opposed to one belonging to a nested call
+  // expression - can be told apart from an ordinary RPAREN.
+  interpStack []int
+
+  // resumeString is set after a ')' closes a pending interpolation; the
+  // next Scan picks back up in the middle of the enclosing STRING literal
+  // rather than starting a fresh token.
+  resumeString bool
+
+  // pendingInterp is set when scanStringBody flushed a non-empty literal
+  // segment right before a "\(" it hasn't consumed yet; the next Scan
+  // emits the INTERPOLATION token for that "\(" instead of starting a
+  // fresh token.
+  pendingInterp bool
+}
+
+// Init prepares the Scanner to read from src, whose contents must be the
+// ones already registered with file (i.e. file.Size() == len(src)).  Errors
+// encountered while scanning are reported to err, if non-nil.  mode controls
+// optional behaviors such as whether comments are returned as tokens.
+func (s *Scanner) Init(file *File, src []byte, err ErrorHandler, mode Mode) {
+  s.file = file
+  s.src = src
+  s.err = err
+  s.mode = mode
+
+  s.ch = ' '
+  s.offset = 0
+  s.rdOffset = 0
+  s.insertSemi = false
+  s.interpStack = nil
+  s.resumeString = false
+  s.pendingInterp = false
+
+  s.next()
+}
+
+// next advances to the next UTF-8-decoded rune in src, setting ch to eof
+// once the source is exhausted.  Every newline crossed is recorded with the
+// owning File so positions keep resolving to the correct line and column.
+func (s *Scanner) next() {
+  if s.ch == '\n' {
+    s.file.AddLine(s.rdOffset)
+  }
+  if s.rdOffset >= len(s.src) {
+    s.offset = len(s.src)
+    s.ch = eof
+    return
+  }
+  r, width := utf8.DecodeRune(s.src[s.rdOffset:])
+  s.offset = s.rdOffset
+  s.ch = r
+  s.rdOffset += width
+}
+
+// peek returns the rune after ch without consuming it, or eof.
+func (s *Scanner) peek() rune {
+  if s.rdOffset >= len(s.src) {
+    return eof
+  }
+  r, _ := utf8.DecodeRune(s.src[s.rdOffset:])
+  return r
+}
+
+func (s *Scanner) error(pos Pos, msg string) {
+  if s.err != nil {
+    s.err(s.file.Position(pos), msg)
+  }
+}
+
+// skipWhitespace consumes spaces, tabs and carriage returns, and newlines
+// too unless insertSemi means this newline must turn into a SEMICOLON.
+func (s *Scanner) skipWhitespace() {
+  for s.ch == ' ' || s.ch == '\t' || s.ch == '\r' || (s.ch == '\n' && !s.insertSemi) {
+    s.next()
+  }
+}
+
+func isLetter(ch rune) bool {
+  return ch == '_' || unicode.IsLetter(ch)
+}
+
+func isDigit(ch rune) bool {
+  return unicode.IsDigit(ch)
+}
+
+func isDecimalDigit(ch rune) bool {
+  return '0' <= ch && ch <= '9'
+}
+
+func isHexDigit(ch rune) bool {
+  return isDecimalDigit(ch) || ('a' <= ch && ch <= 'f') || ('A' <= ch && ch <= 'F')
+}
+
+func isOctalDigit(ch rune) bool {
+  return '0' <= ch && ch <= '7'
+}
+
+func isBinaryDigit(ch rune) bool {
+  return ch == '0' || ch == '1'
+}
+
+func (s *Scanner) scanIdentifier() string {
+  start := s.offset
+  for isLetter(s.ch) || isDigit(s.ch) {
+    s.next()
+  }
+  return string(s.src[start:s.offset])
+}
+
+// scanDigits consumes a run of digits accepted by valid.
+func (s *Scanner) scanDigits(valid func(rune) bool) {
+  for valid(s.ch) {
+    s.next()
+  }
+}
+
+// scanNumber scans an INTEGER in decimal, hex (0x), octal (0o) or binary
+// (0b) form, or a RATIONAL such as "3/4" - told apart from the SLASH
+// operator by requiring a digit immediately after the '/'.
+func (s *Scanner) scanNumber() (Token, string) {
+  start := s.offset
+
+  if s.ch == '0' {
+    switch s.peek() {
+    case 'x', 'X':
+      s.next()
+      s.next()
+      s.scanDigits(isHexDigit)
+      return INTEGER, string(s.src[start:s.offset])
+    case 'o', 'O':
+      s.next()
+      s.next()
+      s.scanDigits(isOctalDigit)
+      return INTEGER, string(s.src[start:s.offset])
+    case 'b', 'B':
+      s.next()
+      s.next()
+      s.scanDigits(isBinaryDigit)
+      return INTEGER, string(s.src[start:s.offset])
+    }
+  }
+
+  s.scanDigits(isDecimalDigit)
+
+  if s.ch == '/' && isDecimalDigit(s.peek()) {
+    s.next()
+    s.scanDigits(isDecimalDigit)
+    return RATIONAL, string(s.src[start:s.offset])
+  }
+
+  return INTEGER, string(s.src[start:s.offset])
+}
+
+// scanChar scans a CHAR literal, given that the opening quote has already
+// been consumed, and returns the character it denotes with any escape
+// sequence decoded.  The closing quote is consumed but not returned.
+func (s *Scanner) scanChar() (Token, string) {
+  if s.ch == eof || s.ch == '\n' {
+    s.error(s.file.Pos(s.offset), "character literal not terminated")
+    return CHAR, ""
+  }
+  if s.ch == '\'' {
+    s.error(s.file.Pos(s.offset-1), "empty character literal")
+    s.next()
+    return CHAR, ""
+  }
+
+  var r rune
+  if s.ch == '\\' {
+    r = s.scanEscape()
+  } else {
+    r = s.ch
+    s.next()
+  }
+
+  if s.ch != '\'' {
+    s.error(s.file.Pos(s.offset), "character literal not terminated")
+  } else {
+    s.next()
+  }
+  return CHAR, string(r)
+}
+
+// scanComment scans a line comment ("//...") or a block comment ("/*...*/"),
+// given that the leading '/' has already been consumed and s.ch is the
+// character that identified which kind it is.  The returned literal
+// includes the delimiters.
+func (s *Scanner) scanComment() string {
+  start := s.offset - 1 // include the leading '/'
+
+  if s.ch == '/' {
+    for s.ch != '\n' && s.ch != eof {
+      s.next()
+    }
+    return string(s.src[start:s.offset])
+  }
+
+  s.next() // consume '*'
+  for {
+    if s.ch == eof {
+      s.error(s.file.Pos(s.offset), "comment not terminated")
+      break
+    }
+    if s.ch == '*' && s.peek() == '/' {
+      s.next()
+      s.next()
+      break
+    }
+    s.next()
+  }
+  return string(s.src[start:s.offset])
+}
+
+// enterInterpolation records that an interpolated expression has just been
+// opened by a "\(" lexeme, so the scanner can find its matching ')'.
+func (s *Scanner) enterInterpolation() {
+  s.interpStack = append(s.interpStack, 0)
+}
+
+// openParen accounts for an ordinary '(' seen while inside an interpolated
+// expression, so a later ')' knows whether it belongs to that nesting or
+// closes the interpolation itself.
+func (s *Scanner) openParen() {
+  if n := len(s.interpStack); n > 0 {
+    s.interpStack[n-1]++
+  }
+}
+
+// closeParen accounts for a ')'.  It reports whether this particular ')'
+// closes the innermost pending interpolation, in which case the scanner
+// should resume the STRING literal that contained it.
+func (s *Scanner) closeParen() bool {
+  n := len(s.interpStack)
+  if n == 0 {
+    return false
+  }
+  if s.interpStack[n-1] > 0 {
+    s.interpStack[n-1]--
+    return false
+  }
+  s.interpStack = s.interpStack[:n-1]
+  return true
+}
+
+// scanAttribute consumes an "@identifier(...)" lexeme, given that the '@'
+// has already been consumed.  It only commits to scanning the identifier
+// once it has confirmed, by lookahead, that a '(' follows; otherwise it
+// returns ok=false having consumed nothing beyond the '@', so the caller can
+// fall back to a bare AT token. The parenthesized argument list may itself
+// contain nested parens and string literals, all of which are balanced
+// correctly.
+func (s *Scanner) scanAttribute() (lit string, ok bool) {
+  start := s.offset - 1 // include the already-consumed '@'
+  if !isLetter(s.ch) {
+    return "", false
+  }
+
+  savedCh, savedOffset, savedRdOffset := s.ch, s.offset, s.rdOffset
+  for isLetter(s.ch) || isDigit(s.ch) {
+    s.next()
+  }
+  if s.ch != '(' {
+    s.ch, s.offset, s.rdOffset = savedCh, savedOffset, savedRdOffset
+    return "", false
+  }
+  s.next() // consume '('
+
+  depth := 1
+  for depth > 0 {
+    switch s.ch {
+    case eof:
+      s.error(s.file.Pos(s.offset), "attribute argument list not terminated")
+      return string(s.src[start:s.offset]), true
+
+    case '(':
+      depth++
+      s.next()
+
+    case ')':
+      depth--
+      s.next()
+
+    case '"':
+      s.next()
+      for s.ch != '"' && s.ch != eof {
+        if s.ch == '\\' {
+          s.next()
+        }
+        s.next()
+      }
+      if s.ch == '"' {
+        s.next()
+      }
+
+    default:
+      s.next()
+    }
+  }
+  return string(s.src[start:s.offset]), true
+}
+
+// SplitAttribute splits an ATTRIBUTE literal such as "@retry(3, delay: 2s)"
+// into its name ("retry") and its raw, still-unparsed argument text
+// ("3, delay: 2s"). lit must be the literal text Scanner.Scan returned for
+// an ATTRIBUTE token.
+func SplitAttribute(lit string) (name, args string) {
+  body := strings.TrimPrefix(lit, "@")
+  open := strings.IndexByte(body, '(')
+  if open < 0 || !strings.HasSuffix(body, ")") {
+    return body, ""
+  }
+  return body[:open], body[open+1 : len(body)-1]
+}
+
+// scanStringBody consumes either the remainder of a STRING literal up to its
+// closing quote, or up through the next "\(" that opens an interpolated
+// expression.  It is called both right after the opening '"' and again,
+// via resumeString, right after the ')' that closes an interpolation.
+func (s *Scanner) scanStringBody() (Token, string) {
+  if s.pendingInterp {
+    s.pendingInterp = false
+    s.next() // consume '\'
+    s.next() // consume '('
+    s.enterInterpolation()
+    return INTERPOLATION, `\(`
+  }
+
+  var lit []byte
+  for {
+    switch {
+    case s.ch == '"':
+      s.next()
+      return STRING, string(lit)
+
+    case s.ch == '\\' && s.peek() == '(':
+      if len(lit) > 0 {
+        // Flush the literal segment seen so far; the "\(" itself is
+        // consumed on the next call, once pendingInterp is serviced.
+        s.pendingInterp = true
+        return STRING, string(lit)
+      }
+      s.next() // consume '\'
+      s.next() // consume '('
+      s.enterInterpolation()
+      return INTERPOLATION, `\(`
+
+    case s.ch == '\\':
+      lit = utf8.AppendRune(lit, s.scanEscape())
+
+    case s.ch == eof || s.ch == '\n':
+      s.error(s.file.Pos(s.offset), "string literal not terminated")
+      return STRING, string(lit)
+
+    default:
+      lit = utf8.AppendRune(lit, s.ch)
+      s.next()
+    }
+  }
+}
+
+// scanEscape consumes a backslash escape sequence (the '\' has already been
+// seen) and returns the character it represents.
+func (s *Scanner) scanEscape() rune {
+  s.next() // consume '\'
+  ch := s.ch
+  switch ch {
+  case 'n':
+    ch = '\n'
+  case 't':
+    ch = '\t'
+  case 'r':
+    ch = '\r'
+  case '\\', '"', '\'':
+    // ch is already the literal character
+  default:
+    s.error(s.file.Pos(s.offset), "unknown escape sequence")
+  }
+  s.next()
+  return ch
+}
+
+// Scan reads and returns the next token, its starting position, and its
+// literal text (populated for identifiers, keywords, and literals).  A
+// newline following a token that can end an expression is reported as a
+// synthetic SEMICOLON, matching the automatic semicolon insertion rule used
+// by go/scanner.
+func (s *Scanner) Scan() (pos Pos, tok Token, lit string) {
+  pos, tok, lit = s.scan()
+  if tok != COMMENT {
+    s.insertSemi = tok.canEndStatement()
+  }
+  return pos, tok, lit
+}
+
+func (s *Scanner) scan() (pos Pos, tok Token, lit string) {
+  if s.resumeString || s.pendingInterp {
+    s.resumeString = false
+    pos = s.file.Pos(s.offset)
+    tok, lit = s.scanStringBody()
+    return pos, tok, lit
+  }
+
+scanAgain:
+  s.skipWhitespace()
+  pos = s.file.Pos(s.offset)
+
+  if s.ch == '\n' {
+    s.next()
+    return pos, SEMICOLON, "\n"
+  }
+  if s.ch == eof && s.insertSemi {
+    s.insertSemi = false
+    return pos, SEMICOLON, "\n"
+  }
+
+  switch {
+  case isLetter(s.ch):
+    lit = s.scanIdentifier()
+    tok = TokenizeKeyword(lit)
+    return pos, tok, lit
+
+  case isDecimalDigit(s.ch):
+    tok, lit = s.scanNumber()
+    return pos, tok, lit
+  }
+
+  ch := s.ch
+  s.next()
+
+  switch ch {
+  case eof:
+    tok = EOF
+
+  case '"':
+    tok, lit = s.scanStringBody()
+
+  case '\'':
+    tok, lit = s.scanChar()
+
+  case '(':
+    tok = LPAREN
+    s.openParen()
+
+  case ')':
+    tok = RPAREN
+    if s.closeParen() {
+      s.resumeString = true
+    }
+
+  case '+':
+    switch {
+    case s.ch == '=':
+      s.next()
+      tok = PLUS_ASSIGN
+    case s.ch == '+':
+      s.next()
+      tok = INC
+    default:
+      tok = PLUS
+    }
+  case '-':
+    switch {
+    case s.ch == '=':
+      s.next()
+      tok = MINUS_ASSIGN
+    case s.ch == '-':
+      s.next()
+      tok = DEC
+    default:
+      tok = MINUS
+    }
+  case '*':
+    tok = SPLAT
+    if s.ch == '=' {
+      s.next()
+      tok = SPLAT_ASSIGN
+    }
+  case '/':
+    switch {
+    case s.ch == '/' || s.ch == '*':
+      comment := s.scanComment()
+      if s.mode&ScanComments == 0 {
+        goto scanAgain
+      }
+      tok = COMMENT
+      lit = comment
+    case s.ch == '=':
+      s.next()
+      tok = SLASH_ASSIGN
+    default:
+      tok = SLASH
+    }
+  case '%':
+    tok = PERCENT
+    if s.ch == '=' {
+      s.next()
+      tok = PERCENT_ASSIGN
+    }
+  case '^':
+    tok = CARAT
+    if s.ch == '=' {
+      s.next()
+      tok = CARAT_ASSIGN
+    }
+  case '{':
+    tok = LBRACE
+  case '}':
+    tok = RBRACE
+  case '[':
+    tok = LBRACKET
+  case ']':
+    tok = RBRACKET
+  case '&':
+    tok = AMPERSAND
+    if s.ch == '=' {
+      s.next()
+      tok = AMPERSAND_ASSIGN
+    }
+  case '~':
+    tok = TILDE
+    if s.ch == '=' {
+      s.next()
+      tok = TILDE_ASSIGN
+    }
+  case '|':
+    tok = PIPE
+    if s.ch == '=' {
+      s.next()
+      tok = PIPE_ASSIGN
+    }
+  case ',':
+    tok = COMMA
+  case '.':
+    tok = PERIOD
+  case ';':
+    tok = SEMICOLON
+  case ':':
+    tok = COLON
+  case '?':
+    tok = QUESTION
+  case '!':
+    tok = BANG
+  case '=':
+    tok = EQUATION
+  case '#':
+    tok = LEN
+  case '@':
+    if attrLit, ok := s.scanAttribute(); ok {
+      tok = ATTRIBUTE
+      lit = attrLit
+    } else {
+      tok = AT
+    }
+  case '$':
+    tok = BLING
+
+  default:
+    tok = INVALID
+    lit = string(ch)
+    s.error(pos, "unexpected character "+lit)
+  }
+
+  return pos, tok, lit
+}
+
+// InterpolatedString reassembles the STRING/INTERPOLATION/.../RPAREN token
+// sequence Scanner emits for one interpolated string literal.  A parser
+// drives it by feeding every token it reads while the interpolation is
+// still open, so the pieces can be folded into a single AST node once
+// EndExpr brings it back to literal text.
+type InterpolatedString struct {
+  // Literals holds the literal text between expressions; len(Literals) is
+  // len(Exprs)+1 whenever no expression is open (between BeginExpr and
+  // EndExpr the two lengths are equal).
+  Literals []string
+  // Exprs holds the token/literal pairs making up each embedded expression,
+  // in source order, for the parser to re-parse into sub-expressions.
+  Exprs [][]struct {
+    Tok Token
+    Lit string
+  }
+}
+
+// NewInterpolatedString returns an InterpolatedString ready to accumulate
+// the pieces of a single STRING literal, starting with its first segment.
+func NewInterpolatedString() *InterpolatedString {
+  return &InterpolatedString{Literals: []string{""}}
+}
+
+// AddLiteral appends text to the literal segment currently being
+// accumulated.
+func (s *InterpolatedString) AddLiteral(text string) {
+  s.Literals[len(s.Literals)-1] += text
+}
+
+// BeginExpr starts a new embedded expression, opened by an INTERPOLATION
+// token the parser just consumed.
+func (s *InterpolatedString) BeginExpr() {
+  s.Exprs = append(s.Exprs, nil)
+}
+
+// AddExprToken records one token of the expression currently open.
+func (s *InterpolatedString) AddExprToken(tok Token, lit string) {
+  i := len(s.Exprs) - 1
+  s.Exprs[i] = append(s.Exprs[i], struct {
+    Tok Token
+    Lit string
+  }{tok, lit})
+}
+
+// EndExpr closes the expression currently open, in response to the RPAREN
+// that matched its INTERPOLATION, and starts the next literal segment.
+func (s *InterpolatedString) EndExpr() {
+  s.Literals = append(s.Literals, "")
+}
@@ -0,0 +1,298 @@
+package lexer
+
+import "testing"
+
+// scan runs src through a fresh Scanner and returns every (tok, lit) pair up
+// to and including EOF.
+func scan(t *testing.T, src string) []struct {
+  Tok Token
+  Lit string
+} {
+  t.Helper()
+  return scanMode(t, src, 0)
+}
+
+// scanMode is like scan but lets the caller pick the Scanner's Mode, e.g. to
+// exercise ScanComments.
+func scanMode(t *testing.T, src string, mode Mode) []struct {
+  Tok Token
+  Lit string
+} {
+  t.Helper()
+  fset := NewFileSet()
+  file := fset.AddFile("t", len(src))
+  var s Scanner
+  s.Init(file, []byte(src), func(pos Position, msg string) {
+    t.Errorf("%v: %s", pos, msg)
+  }, mode)
+
+  var got []struct {
+    Tok Token
+    Lit string
+  }
+  for {
+    _, tok, lit := s.Scan()
+    got = append(got, struct {
+      Tok Token
+      Lit string
+    }{tok, lit})
+    if tok == EOF {
+      return got
+    }
+  }
+}
+
+func tokens_(toks ...Token) []Token { return toks }
+
+func tokensOf(got []struct {
+  Tok Token
+  Lit string
+}) []Token {
+  out := make([]Token, len(got))
+  for i, g := range got {
+    out[i] = g.Tok
+  }
+  return out
+}
+
+func assertTokens(t *testing.T, got []struct {
+  Tok Token
+  Lit string
+}, want []Token) {
+  t.Helper()
+  gotToks := tokensOf(got)
+  if len(gotToks) != len(want) {
+    t.Fatalf("got %d tokens %v, want %d %v", len(gotToks), gotToks, len(want), want)
+  }
+  for i := range want {
+    if gotToks[i] != want[i] {
+      t.Fatalf("token %d: got %v, want %v (full: %v)", i, gotToks[i], want[i], gotToks)
+    }
+  }
+}
+
+func TestASIInsertsAfterExpressionEndingTokens(t *testing.T) {
+  got := scan(t, "x\n1\n")
+  assertTokens(t, got, tokens_(ID, SEMICOLON, INTEGER, SEMICOLON, EOF))
+}
+
+func TestASIDoesNotDoubleInsertAcrossBlankLines(t *testing.T) {
+  got := scan(t, "x\n\n\ny")
+  assertTokens(t, got, tokens_(ID, SEMICOLON, ID, SEMICOLON, EOF))
+}
+
+func TestASIDoesNotFireAfterOperators(t *testing.T) {
+  got := scan(t, "x +\ny")
+  assertTokens(t, got, tokens_(ID, PLUS, ID, SEMICOLON, EOF))
+}
+
+// Regression: ATTRIBUTE falls within the literal_start/literal_limit range,
+// but a newline right after "@attr(...)" must not insert a SEMICOLON before
+// the declaration it decorates.
+func TestASIDoesNotFireAfterAttribute(t *testing.T) {
+  got := scan(t, "@retry(3)\ncell foo = 1\n")
+  assertTokens(t, got,
+    tokens_(ATTRIBUTE, CELL, ID, EQUATION, INTEGER, SEMICOLON, EOF))
+}
+
+// Regression: INTERPOLATION is also in the literal range but never ends a
+// statement - there is always more string to come, even across a newline
+// sitting directly inside the now-reopened expression context.
+func TestASIDoesNotFireAfterInterpolationOpen(t *testing.T) {
+  got := scan(t, "\"\\(\nx)\"")
+  assertTokens(t, got,
+    tokens_(INTERPOLATION, ID, RPAREN, STRING, SEMICOLON, EOF))
+}
+
+func TestUnicodeIdentifier(t *testing.T) {
+  got := scan(t, "héllo")
+  assertTokens(t, got, tokens_(ID, SEMICOLON, EOF))
+  if got[0].Lit != "héllo" {
+    t.Fatalf("lit = %q, want %q", got[0].Lit, "héllo")
+  }
+}
+
+func TestAttributeUnicodeName(t *testing.T) {
+  got := scan(t, "@héllo(1)")
+  if got[0].Tok != ATTRIBUTE {
+    t.Fatalf("tok = %v, want ATTRIBUTE (lit=%q)", got[0].Tok, got[0].Lit)
+  }
+  if got[0].Lit != "@héllo(1)" {
+    t.Fatalf("lit = %q, want %q", got[0].Lit, "@héllo(1)")
+  }
+}
+
+func TestSplitAttribute(t *testing.T) {
+  cases := []struct {
+    src        string
+    name, args string
+  }{
+    {"@retry(3)", "retry", "3"},
+    {`@retry(3, msg("a)b"))`, "retry", `3, msg("a)b")`},
+    {"@foo()", "foo", ""},
+  }
+  for _, c := range cases {
+    got := scan(t, c.src)
+    if got[0].Tok != ATTRIBUTE {
+      t.Fatalf("scan(%q) tok = %v, want ATTRIBUTE", c.src, got[0].Tok)
+    }
+    name, args := SplitAttribute(got[0].Lit)
+    if name != c.name || args != c.args {
+      t.Errorf("SplitAttribute(%q) = %q, %q, want %q, %q", got[0].Lit, name, args, c.name, c.args)
+    }
+  }
+}
+
+func TestAttributeFallsBackToATWithoutParens(t *testing.T) {
+  got := scan(t, "@ x")
+  assertTokens(t, got, tokens_(AT, ID, SEMICOLON, EOF))
+}
+
+func TestInterpolationNestedParens(t *testing.T) {
+  // "\(" opens an expression that itself calls a function, so the ')'
+  // closing that call must not be mistaken for the one closing the
+  // interpolation.
+  got := scan(t, `"\(f(x))"`)
+  assertTokens(t, got,
+    tokens_(INTERPOLATION, ID, LPAREN, ID, RPAREN, RPAREN, STRING, SEMICOLON, EOF))
+}
+
+// Regression: a literal segment preceding "\(" must not be dropped, even
+// though the "\(" is matched one character ahead via peek().
+func TestInterpolationPreservesLeadingLiteral(t *testing.T) {
+  got := scan(t, `"a\(x)"`)
+  if got[0].Tok != STRING || got[0].Lit != "a" {
+    t.Fatalf("got tok=%v lit=%q, want STRING %q", got[0].Tok, got[0].Lit, "a")
+  }
+  assertTokens(t, got, tokens_(STRING, INTERPOLATION, ID, RPAREN, STRING, SEMICOLON, EOF))
+}
+
+func TestInterpolationMultipleExpressions(t *testing.T) {
+  got := scan(t, `"a\(x)b\(y)c"`)
+  assertTokens(t, got, tokens_(
+    STRING, INTERPOLATION, ID, RPAREN,
+    STRING, INTERPOLATION, ID, RPAREN,
+    STRING, SEMICOLON, EOF))
+}
+
+// TestInterpolatedStringReassembly drives InterpolatedString through the
+// token sequence Scan produces for "a\(x)b\(y)c", mirroring how a parser
+// would fold STRING/INTERPOLATION/.../RPAREN into one AST node.
+func TestInterpolatedStringReassembly(t *testing.T) {
+  got := scan(t, `"a\(x)b\(y)c"`)
+
+  str := NewInterpolatedString()
+  for _, g := range got {
+    switch g.Tok {
+    case STRING:
+      str.AddLiteral(g.Lit)
+    case INTERPOLATION:
+      str.BeginExpr()
+    case RPAREN:
+      str.EndExpr()
+    case EOF, SEMICOLON:
+      // not part of the string literal
+    default:
+      str.AddExprToken(g.Tok, g.Lit)
+    }
+  }
+
+  wantLiterals := []string{"a", "b", "c"}
+  if len(str.Literals) != len(wantLiterals) {
+    t.Fatalf("Literals = %v, want %v", str.Literals, wantLiterals)
+  }
+  for i, want := range wantLiterals {
+    if str.Literals[i] != want {
+      t.Errorf("Literals[%d] = %q, want %q", i, str.Literals[i], want)
+    }
+  }
+
+  if len(str.Exprs) != 2 {
+    t.Fatalf("got %d Exprs, want 2", len(str.Exprs))
+  }
+  wantExprLits := []string{"x", "y"}
+  for i, want := range wantExprLits {
+    if len(str.Exprs[i]) != 1 || str.Exprs[i][0].Tok != ID || str.Exprs[i][0].Lit != want {
+      t.Errorf("Exprs[%d] = %v, want single ID %q", i, str.Exprs[i], want)
+    }
+  }
+}
+
+func TestEmptyCharLiteralReportsErrorAtOpeningQuote(t *testing.T) {
+  fset := NewFileSet()
+  src := []byte("''")
+  file := fset.AddFile("t", len(src))
+  var s Scanner
+  var msgs []string
+  var positions []Position
+  s.Init(file, src, func(pos Position, msg string) {
+    msgs = append(msgs, msg)
+    positions = append(positions, pos)
+  }, 0)
+
+  _, tok, lit := s.Scan()
+  if tok != CHAR || lit != "" {
+    t.Fatalf("got tok=%v lit=%q, want CHAR \"\"", tok, lit)
+  }
+  if len(msgs) != 1 || msgs[0] != "empty character literal" {
+    t.Fatalf("errors = %v, want exactly [\"empty character literal\"]", msgs)
+  }
+  if positions[0].Offset != 0 || positions[0].Column != 1 {
+    t.Fatalf("error at offset %d col %d, want offset 0 col 1 (opening quote)",
+      positions[0].Offset, positions[0].Column)
+  }
+}
+
+func TestCharLiteralWithEscape(t *testing.T) {
+  got := scan(t, `'\n'`)
+  if got[0].Tok != CHAR || got[0].Lit != "\n" {
+    t.Fatalf("got tok=%v lit=%q, want CHAR %q", got[0].Tok, got[0].Lit, "\n")
+  }
+}
+
+func TestNumberBasesAndRational(t *testing.T) {
+  cases := []struct {
+    src string
+    tok Token
+    lit string
+  }{
+    {"0x1F", INTEGER, "0x1F"},
+    {"0o17", INTEGER, "0o17"},
+    {"0b101", INTEGER, "0b101"},
+    {"3/4", RATIONAL, "3/4"},
+    {"10", INTEGER, "10"},
+  }
+  for _, c := range cases {
+    got := scan(t, c.src)
+    if got[0].Tok != c.tok || got[0].Lit != c.lit {
+      t.Errorf("scan(%q) = %v %q, want %v %q", c.src, got[0].Tok, got[0].Lit, c.tok, c.lit)
+    }
+  }
+}
+
+func TestCommentsAreSkippedByDefault(t *testing.T) {
+  got := scan(t, "x // trailing comment\ny")
+  assertTokens(t, got, tokens_(ID, SEMICOLON, ID, SEMICOLON, EOF))
+}
+
+func TestBlockCommentSpansLines(t *testing.T) {
+  got := scan(t, "x /* spans\nmultiple\nlines */ y")
+  assertTokens(t, got, tokens_(ID, ID, SEMICOLON, EOF))
+}
+
+// Regression: a COMMENT token returned under ScanComments must not clear
+// insertSemi - the newline that follows a trailing comment still ends the
+// statement the comment was trailing.
+func TestCommentDoesNotSuppressASI(t *testing.T) {
+  got := scanMode(t, "x // trailing\ny\n", ScanComments)
+  assertTokens(t, got,
+    tokens_(ID, COMMENT, SEMICOLON, ID, SEMICOLON, EOF))
+}
+
+func TestCompoundAssignAndIncDec(t *testing.T) {
+  got := scan(t, "x += 1\ny++\nz--")
+  assertTokens(t, got, tokens_(
+    ID, PLUS_ASSIGN, INTEGER, SEMICOLON,
+    ID, INC, SEMICOLON,
+    ID, DEC, SEMICOLON, EOF))
+}
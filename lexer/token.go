@@ -7,23 +7,25 @@
 
 package lexer
 
-import "strcopy"
+import "strconv"
 
 type Token int
 
-const {
+const (
   INVALID Token = iota
   EOF
   COMMENT
-  
+
   literal_start
   ID
   INTEGER
   RATIONAL
   CHAR
   STRING
+  INTERPOLATION // the "\(" that opens an interpolated expression inside a STRING
+  ATTRIBUTE     // a whole "@name(args...)" lexeme
   literal_limit
-  
+
   operator_start
   PLUS
   MINUS
@@ -31,23 +33,23 @@ const {
   SLASH
   PERCENT
   CARAT
-  
+
   BOOL_AND
   BOOL_OR
   BOOL_EQ
+  BOOL_NE
   BOOL_LT
   BOOL_GT
   BOOL_LTE
   BOOL_GTE
-  BOOL_INV
-  
+
   LPAREN
   RPAREN
   LBRACE
   RBRACE
   LBRACKET
   RBRACKET
-  
+
   AMPERSAND
   TILDE
   PIPE
@@ -58,17 +60,30 @@ const {
   ELLIPSIS
   QUESTION
   BANG
-  
+
   EQUATION
   TYPEDEF
   LEN
   ARROW
   IMPLY
-  
+
+  PLUS_ASSIGN
+  MINUS_ASSIGN
+  SPLAT_ASSIGN
+  SLASH_ASSIGN
+  PERCENT_ASSIGN
+  CARAT_ASSIGN
+  AMPERSAND_ASSIGN
+  PIPE_ASSIGN
+  TILDE_ASSIGN
+
+  INC
+  DEC
+
   AT
   BLING
   operator_limit
-  
+
   keyword_start
   ENV
   CELL
@@ -78,7 +93,7 @@ const {
   JOB
   STORAGE
   MUTABLE
-  
+
   BOOL_TRUE
   BOOL_FALSE
 
@@ -89,7 +104,7 @@ const {
   IN
   CONTAINS
   keyword_limit
-}
+)
 
 func (token Token) IsLiteral() bool {
   return literal_start < token && token < literal_limit
@@ -103,22 +118,50 @@ func (token Token) IsKeyword() bool {
   return keyword_start < token && token < keyword_limit
 }
 
-var tokens = [...]string(
-  TOKEN_ERR: "TOKEN_ERR",
-  END: "END",
+// canEndStatement reports whether a newline following this token should be
+// turned into an automatically inserted SEMICOLON by the Scanner: after an
+// identifier, a literal, a closing ")", "]" or "}", or "++"/"--".  This deliberately
+// does not delegate to IsLiteral(), since INTERPOLATION and ATTRIBUTE also
+// fall in the literal range but cannot end a statement - a "\(" always has
+// more string to come, and an ATTRIBUTE is followed by the declaration it
+// decorates, not a statement boundary.
+func (token Token) canEndStatement() bool {
+  switch token {
+  case ID, INTEGER, RATIONAL, CHAR, STRING,
+    RPAREN, RBRACKET, RBRACE, INC, DEC:
+    return true
+  }
+  return false
+}
+
+// Decoratable reports whether the grammar allows one or more ATTRIBUTE
+// lexemes to precede this keyword, decorating the declaration it begins.
+func (token Token) Decoratable() bool {
+  switch token {
+  case CELL, SERVICE, COMPONENT, JOB, STORAGE:
+    return true
+  }
+  return false
+}
+
+var tokens = [...]string{
+  INVALID: "INVALID",
+  EOF:     "EOF",
   COMMENT: "COMMENT",
-  
-  ID: "ID",
-  INTEGER: "INTEGER",
-  RATIONAL: "RATIONAL",
-  CHAR: "CHAR",
-  STRING: "STRING",
-  
+
+  ID:            "ID",
+  INTEGER:       "INTEGER",
+  RATIONAL:      "RATIONAL",
+  CHAR:          "CHAR",
+  STRING:        "STRING",
+  INTERPOLATION: "INTERPOLATION",
+  ATTRIBUTE:     "ATTRIBUTE",
+
   PLUS:    "+",
   MINUS:   "-",
   SPLAT:   "*",
   SLASH:   "/",
-  PERCENT: "%%",
+  PERCENT: "%",
   CARAT:   "^",
 
   BOOL_AND: "and",
@@ -154,38 +197,51 @@ var tokens = [...]string(
   ARROW:    "->",
   IMPLY:    "=>",
 
+  PLUS_ASSIGN:      "+=",
+  MINUS_ASSIGN:     "-=",
+  SPLAT_ASSIGN:     "*=",
+  SLASH_ASSIGN:     "/=",
+  PERCENT_ASSIGN:   "%=",
+  CARAT_ASSIGN:     "^=",
+  AMPERSAND_ASSIGN: "&=",
+  PIPE_ASSIGN:      "|=",
+  TILDE_ASSIGN:     "~=",
+
+  INC: "++",
+  DEC: "--",
+
   // Operators reserved for future use
   AT:    "@",
   BLING: "$",
 
   // Reserved keywords
-  ENV: "env",
-  CELL: "cell",
-  USER: "user",
-  SERVICE: "service",
+  ENV:       "env",
+  CELL:      "cell",
+  USER:      "user",
+  SERVICE:   "service",
   COMPONENT: "component",
-  JOB: "job",
-  STORAGE: "storage",
-  MUTABLE: "mutable",
+  JOB:       "job",
+  STORAGE:   "storage",
+  MUTABLE:   "mutable",
 
-  BOOL_TRUE: "true",
+  BOOL_TRUE:  "true",
   BOOL_FALSE: "false",
 
-  IF: "if",
-  ELSE: "else",
-  UNLESS: "unless",
-  FOREACH: "foreach",
-  IN: "in",
-  CONTAINS: "contains"
-)
+  IF:       "if",
+  ELSE:     "else",
+  UNLESS:   "unless",
+  FOREACH:  "foreach",
+  IN:       "in",
+  CONTAINS: "contains",
+}
 
 // String converts a token into its string representation.
 func (token Token) String() string {
   str := ""
   if token >= 0 && token < Token(len(tokens)) {
-    str = tokens[tok]
+    str = tokens[token]
   } else {
-    str = "<token " + strconf.Itoa(int(token)) + ">"
+    str = "<token " + strconv.Itoa(int(token)) + ">"
   }
   return str
 }
@@ -194,22 +250,22 @@ func (token Token) String() string {
 // Non-operators are given lowest precedence, unary opoerators have very high
 // precedence, and there is a catch-all for the highest-binding operators such
 // as function calls and indexing, dereferencing.
-const {
+const (
   LowestPrecedence = 0  // Everything below operators.
-  
+
   DisjunctionPrecedence  // or
   ConjunctionPrecedence  // and
-  
+
   EqualityPrecedence        // ==, !=
   RelationPrecedence        // <, <=, >=, >, in, contains
   RecordOperatorPrecedence  // &, ~, |
   AdditivePrecedence        // +, -
   MultiplicativePrecedence  // *, /, %
   ExponentialPrecedence     // ^
-  
+
   UnaryPrecedence
   CatchAllPrecedence
-}
+)
 
 // Precedence eturns the precedence of the operator, assuming it is a binary
 // operator.  If not an operator, LowestPrecedence returned.  Otherwise, it
@@ -218,7 +274,7 @@ func (operator Token) Precedence() int {
   switch operator {
   case AMPERSAND, TILDE, PIPE:
     return RecordOperatorPrecedence
-    
+
   case BOOL_OR:
     return DisjunctionPrecedence
 
@@ -228,27 +284,55 @@ func (operator Token) Precedence() int {
   case BOOL_EQ, BOOL_NE:
     return EqualityPrecedence
 
-  case BOOL_LT, BOOL_LTE, BOOL_GTE, BOOL_BT, IN, CONTAINS:
+  case BOOL_LT, BOOL_LTE, BOOL_GTE, BOOL_GT, IN, CONTAINS:
     return RelationPrecedence
 
   case PLUS, MINUS:
     return AdditivePrecedence
-  
+
   case SPLAT, SLASH, PERCENT:
     return MultiplicativePrecedence
-    
+
   case CARAT:
     return ExponentialPrecedence
   }
-  
+
   return LowestPrecedence
 }
 
+// AssignOp returns the binary operator a compound assignment desugars to,
+// e.g. PLUS_ASSIGN.AssignOp() == PLUS, so a parser can lower "x += y" to
+// "x = x + y" uniformly.  It returns INVALID for any token that is not a
+// compound assignment operator.
+func (token Token) AssignOp() Token {
+  switch token {
+  case PLUS_ASSIGN:
+    return PLUS
+  case MINUS_ASSIGN:
+    return MINUS
+  case SPLAT_ASSIGN:
+    return SPLAT
+  case SLASH_ASSIGN:
+    return SLASH
+  case PERCENT_ASSIGN:
+    return PERCENT
+  case CARAT_ASSIGN:
+    return CARAT
+  case AMPERSAND_ASSIGN:
+    return AMPERSAND
+  case PIPE_ASSIGN:
+    return PIPE
+  case TILDE_ASSIGN:
+    return TILDE
+  }
+  return INVALID
+}
+
 var keywords map[string]Token
 
 func init() {
   keywords = make(map[string]Token)
-  for i := keyword_start; i < keyword_limit; i++) {
+  for i := keyword_start; i < keyword_limit; i++ {
     keywords[tokens[i]] = i
   }
 }
@@ -261,4 +345,4 @@ func TokenizeKeyword(ident string) Token {
     return token
   }
   return ID
-}
\ No newline at end of file
+}